@@ -0,0 +1,88 @@
+// Command dbresolver-migrate runs the dbresolver Migrator against every
+// tenant database declared in a dbresolver YAML config.
+//
+// Usage:
+//
+//	dbresolver-migrate -config dbresolver.yaml -migrations ./migrations up
+//	dbresolver-migrate -config dbresolver.yaml -migrations ./migrations down
+//	dbresolver-migrate -config dbresolver.yaml -migrations ./migrations to 3
+//	dbresolver-migrate -config dbresolver.yaml -migrations ./migrations status
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/abiiranathan/dbresolver/dbresolver"
+)
+
+func main() {
+	configPath := flag.String("config", "dbresolver.yaml", "path to the dbresolver YAML config")
+	migrationsDir := flag.String("migrations", "migrations", "directory of versioned .sql migration files")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		log.Fatalln("usage: dbresolver-migrate [-config path] [-migrations dir] up|down|to <version>|status")
+	}
+
+	databaseConfig, err := dbresolver.ConfigFromYAMLFile(*configPath)
+	if err != nil {
+		log.Fatalf("loading config %q: %v", *configPath, err)
+	}
+
+	resolver, err := dbresolver.New(databaseConfig)
+	if err != nil {
+		log.Fatalf("initializing resolver: %v", err)
+	}
+
+	migrator, err := dbresolver.NewMigrator(resolver, *migrationsDir)
+	if err != nil {
+		log.Fatalf("loading migrations from %q: %v", *migrationsDir, err)
+	}
+
+	ctx := context.Background()
+
+	var report dbresolver.Report
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		report = migrator.Up(ctx)
+	case "down":
+		report = migrator.Down(ctx)
+	case "status":
+		report = migrator.Status(ctx)
+	case "to":
+		if flag.NArg() < 2 {
+			log.Fatalln("usage: dbresolver-migrate ... to <version>")
+		}
+		version, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q: %v", flag.Arg(1), err)
+		}
+		report = migrator.To(ctx, version)
+	default:
+		log.Fatalf("unknown command %q (want up, down, to, or status)", cmd)
+	}
+
+	printReport(report)
+}
+
+// printReport prints one line per database and exits non-zero if any
+// database failed to migrate.
+func printReport(report dbresolver.Report) {
+	failed := false
+	for name, result := range report {
+		if result.Err != nil {
+			failed = true
+			fmt.Printf("%s: FAILED after applying %v: %v\n", name, result.Applied, result.Err)
+			continue
+		}
+		fmt.Printf("%s: applied %v\n", name, result.Applied)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
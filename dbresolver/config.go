@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,7 +16,47 @@ DatabaseConfig links API keys to database objects in a map data structure.
 */
 type DatabaseConfig map[string]map[string]string
 
+// UnmarshalYAML lets DatabaseConfig accept real YAML sequences (e.g.
+// `replicas: [dsn-one, dsn-two]`) even though it's stored as a flat
+// map[string]string: each entry is first decoded loosely, then any list
+// value is joined into the same comma-separated form replicasFromMap
+// already expects, and any scalar is stringified as-is.
+func (dbconfig *DatabaseConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw map[string]map[string]interface{}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	parsed := make(DatabaseConfig, len(raw))
+	for key, fields := range raw {
+		normalized := make(map[string]string, len(fields))
+		for field, v := range fields {
+			normalized[field] = stringifyConfigValue(v)
+		}
+		parsed[key] = normalized
+	}
+	*dbconfig = parsed
+	return nil
+}
+
+// stringifyConfigValue flattens a loosely-typed YAML scalar or sequence down
+// to the string DatabaseConfig's map[string]string schema expects.
+func stringifyConfigValue(v interface{}) string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprint(item))
+	}
+	return strings.Join(parts, ",")
+}
+
 // Returns a struct of DBDrivers with each having a Database and Driver field.
+// Per-database connection pool settings (max_open_conns, max_idle_conns,
+// conn_max_lifetime, conn_max_idle_time) are parsed from the same map when
+// present; see DBDriver for their defaults.
 func (dbconfig DatabaseConfig) DatabaseDrivers() []DBDriver {
 	databaseNames := make([]DBDriver, 0, len(dbconfig))
 	for _, dbmap := range dbconfig {
@@ -30,12 +73,63 @@ func (dbconfig DatabaseConfig) DatabaseDrivers() []DBDriver {
 		databaseNames = append(databaseNames, DBDriver{
 			Driver:   Driver(driver),
 			Database: database,
+			Pool:     poolConfigFromMap(dbmap),
+			Replicas: replicasFromMap(dbmap),
+			Policy:   dbmap["policy"],
 		})
 
 	}
 	return databaseNames
 }
 
+// replicasFromMap parses the optional "replicas" key into a slice of DSNs.
+// The value is always a comma-separated string by the time it reaches here:
+// DatabaseConfig's UnmarshalYAML joins a YAML list (`replicas: [dsn-one,
+// dsn-two]`) into this same form, so both that and a literal
+// `replicas: "dsn-one,dsn-two"` work.
+func replicasFromMap(dbmap map[string]string) []string {
+	raw, ok := dbmap["replicas"]
+	if !ok || raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	replicas := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			replicas = append(replicas, p)
+		}
+	}
+	return replicas
+}
+
+// poolConfigFromMap parses the optional pool tuning keys out of a raw YAML
+// entry. Missing or unparsable values are left at their zero value, which
+// tells applyPoolConfig to leave GORM/database/sql's own defaults in place.
+func poolConfigFromMap(dbmap map[string]string) PoolConfig {
+	var pool PoolConfig
+	if v, ok := dbmap["max_open_conns"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			pool.MaxOpenConns = n
+		}
+	}
+	if v, ok := dbmap["max_idle_conns"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			pool.MaxIdleConns = n
+		}
+	}
+	if v, ok := dbmap["conn_max_lifetime"]; ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			pool.ConnMaxLifetime = d
+		}
+	}
+	if v, ok := dbmap["conn_max_idle_time"]; ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			pool.ConnMaxIdleTime = d
+		}
+	}
+	return pool
+}
+
 /*
 DatabaseConfigFromYAML parses a YAML-formatted string and returns a DatabaseConfig.
 */
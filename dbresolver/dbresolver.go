@@ -2,14 +2,23 @@ package dbresolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"gorm.io/driver/clickhouse"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Expected requst header or query
@@ -27,22 +36,200 @@ var DatabaseContextKey ContextValue = "database"
 type Driver string
 
 const (
-	Sqlite   Driver = "sqlite"
-	MySQL    Driver = "mysql"
-	Postgres Driver = "postgres"
+	Sqlite     Driver = "sqlite"
+	MySQL      Driver = "mysql"
+	Postgres   Driver = "postgres"
+	MSSQL      Driver = "mssql"
+	ClickHouse Driver = "clickhouse"
 )
 
 type DBDriver struct {
 	Driver   Driver
 	Database string
+	// Pool holds the per-database connection pool tuning parsed from the
+	// YAML config. Zero values leave database/sql's own defaults in place.
+	Pool PoolConfig
+
+	// Replicas holds read-replica DSNs for this database. When non-empty,
+	// reads are load-balanced across them via gorm.io/plugin/dbresolver
+	// while writes (and transactions) go to Database, the primary.
+	Replicas []string
+	// Policy selects how reads are balanced across Replicas: "random"
+	// (default) or "round-robin".
+	Policy string
+}
+
+// PoolConfig tunes the underlying database/sql connection pool for a single
+// tenant database. A zero value for any field means "leave the driver's
+// default alone" - it is never forced down to 0/unlimited.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// applyPoolConfig pushes the tuning in p onto the *sql.DB backing conn.
+func applyPoolConfig(conn *gorm.DB, p PoolConfig) error {
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return err
+	}
+	if p.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+	return nil
+}
+
+// dbEntry lazily opens a single tenant's *gorm.DB. The connection is only
+// established on first use (when DBResolver.lazyConnect is set). mu guards
+// opened/db/err/healthy; opened is only latched to true on a *successful*
+// open, so a tenant whose database is briefly unreachable on first touch is
+// retried on the next call to open rather than being stuck failed until a
+// process restart.
+type dbEntry struct {
+	driver    DBDriver
+	dialector gorm.Dialector
+	resolver  *DBResolver
+
+	mu      sync.RWMutex
+	opened  bool
+	db      *gorm.DB
+	err     error
+	healthy bool
+}
+
+// open establishes the connection if it hasn't succeeded yet and applies
+// pool tuning. A failed attempt is not cached - the next call retries.
+func (e *dbEntry) open(config *gorm.Config) (*gorm.DB, error) {
+	e.mu.RLock()
+	if e.opened {
+		db, err := e.db, e.err
+		e.mu.RUnlock()
+		return db, err
+	}
+	e.mu.RUnlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.opened {
+		return e.db, e.err
+	}
+
+	db, err := createDB(e.dialector, config)
+	if err == nil {
+		err = applyPoolConfig(db, e.driver.Pool)
+	}
+	if err == nil {
+		err = registerReadReplicas(db, e.driver)
+	}
+	if err == nil {
+		err = registerQueryMetrics(db, e.driver.Database, e.resolver)
+	}
+
+	e.db, e.err = db, err
+	e.healthy = err == nil
+	e.opened = err == nil
+	return e.db, e.err
+}
+
+// get returns the entry's current connection and health without attempting
+// to open it. Used by the health checker, which must not force a lazy
+// connection open just to report that it has never been connected.
+func (e *dbEntry) get() (*gorm.DB, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.db, e.healthy
+}
+
+// reconnect reopens the underlying connection, replacing db/err/healthy and
+// closing the previous connection (if any) so its pool isn't leaked. Unlike
+// open, it can run repeatedly from the health checker after a previously
+// successful connection goes bad. On failure only healthy is cleared: open
+// keeps returning the previous connection, and resolveConnection reports
+// ErrUnhealthy for it until a later reconnect succeeds.
+func (e *dbEntry) reconnect(config *gorm.Config) error {
+	db, err := createDB(e.dialector, config)
+	if err == nil {
+		err = applyPoolConfig(db, e.driver.Pool)
+	}
+	if err == nil {
+		err = registerReadReplicas(db, e.driver)
+	}
+	if err == nil {
+		err = registerQueryMetrics(db, e.driver.Database, e.resolver)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.healthy = false
+		return err
+	}
+
+	if e.db != nil {
+		if oldSQLDB, closeErr := e.db.DB(); closeErr == nil {
+			oldSQLDB.Close()
+		}
+	}
+	e.db, e.err = db, nil
+	e.healthy = true
+	e.opened = true
+	return nil
 }
 
 // DBResolver stores all database connections and config.
 // Call ResolveConnection to get the underlying database connection for an API key.
 type DBResolver struct {
-	conns          map[string]*gorm.DB
+	// mu guards conns and databaseConfig, both of which are mutated after
+	// New by AddTenant, RemoveTenant, and ReloadFromYAML.
+	mu             sync.RWMutex
+	conns          map[string]*dbEntry
 	databaseConfig DatabaseConfig
 	config         *gorm.Config
+	lazyConnect    bool
+
+	healthCheckInterval time.Duration
+	stopHealthCheck     chan struct{}
+
+	configWatchPath string
+	stopConfigWatch chan struct{}
+
+	keyExtractors []KeyExtractor
+
+	metrics  *resolverMetrics
+	observer func(QueryEvent)
+	tracer   trace.Tracer
+}
+
+// snapshotConns returns a shallow copy of the current connection map, safe
+// to range over without holding mu for the duration of a (possibly slow)
+// operation like AutoMigrate or a health check sweep.
+func (resolver *DBResolver) snapshotConns() map[string]*dbEntry {
+	resolver.mu.RLock()
+	defer resolver.mu.RUnlock()
+	snapshot := make(map[string]*dbEntry, len(resolver.conns))
+	for name, entry := range resolver.conns {
+		snapshot[name] = entry
+	}
+	return snapshot
+}
+
+// getEntry looks up a tenant's dbEntry by database name.
+func (resolver *DBResolver) getEntry(databaseName string) (*dbEntry, bool) {
+	resolver.mu.RLock()
+	defer resolver.mu.RUnlock()
+	entry, ok := resolver.conns[databaseName]
+	return entry, ok
 }
 
 type Option func(resolver *DBResolver)
@@ -53,20 +240,57 @@ func GormConfig(c *gorm.Config) Option {
 	}
 }
 
+// LazyConnect defers opening each tenant's connection until it is first
+// resolved (e.g. the first request authenticated with its API key), instead
+// of opening every configured database up front. Useful for deployments with
+// hundreds of tenants where most databases may never be touched.
+func LazyConnect() Option {
+	return func(resolver *DBResolver) {
+		resolver.lazyConnect = true
+	}
+}
+
 // Changes the expected header or query param for the API key.
 func SetHeaderName(name string) {
 	apiKeyHeader = name
 }
 
+// dialectorFor builds the gorm.Dialector for a DBDriver's driver/database pair.
+// Built-in drivers are tried first; anything else falls through to dialects
+// registered with RegisterDialect.
+func dialectorFor(dbDriver DBDriver) (gorm.Dialector, error) {
+	switch dbDriver.Driver {
+	case Sqlite:
+		return sqlite.Open(dbDriver.Database), nil
+	case MySQL:
+		return mysql.Open(dbDriver.Database), nil
+	case Postgres:
+		return postgres.Open(dbDriver.Database), nil
+	case MSSQL:
+		return sqlserver.Open(dbDriver.Database), nil
+	case ClickHouse:
+		return clickhouse.Open(dbDriver.Database), nil
+	}
+
+	if opener, ok := lookupDialect(dbDriver.Driver); ok {
+		return opener(dbDriver.Database), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q (supported: %s)",
+		dbDriver.Driver, strings.Join(supportedDrivers(), ", "))
+}
+
 // Initialize a new DBResolver with a database config, driver, and *gorm.Config.
 // The driver argument should be one of "sqlite", "mysql", or "postgres".
 // Default ApiKey header/query expected is x-api-key.
 // call dbresolver.SetHeaderName to change it.
+// By default every configured database is opened eagerly; pass LazyConnect()
+// to defer opening until a connection is first resolved.
 func New(c DatabaseConfig, options ...Option) (*DBResolver, error) {
 	resolver := &DBResolver{
-		conns:          make(map[string]*gorm.DB),
+		conns:          make(map[string]*dbEntry),
 		databaseConfig: c,
 		config:         &gorm.Config{},
+		metrics:        newResolverMetrics(),
 	}
 
 	// Apply all the options
@@ -75,33 +299,48 @@ func New(c DatabaseConfig, options ...Option) (*DBResolver, error) {
 	}
 
 	for _, dbDriver := range c.DatabaseDrivers() {
-		database := dbDriver.Database
-		var dialect gorm.Dialector
-
-		switch dbDriver.Driver {
-		case Sqlite:
-			dialect = sqlite.Open(string(database))
-		case MySQL:
-			dialect = mysql.Open(string(database))
-		case Postgres:
-			dialect = postgres.Open(string(database))
-		default:
-			return nil, fmt.Errorf("unsupported database driver: %s", dbDriver.Driver)
-		}
-
-		// Create database connection with correct dialect.
-		conn, err := createDB(dialect, resolver.config)
+		dialect, err := dialectorFor(dbDriver)
 		if err != nil {
 			return nil, err
 		}
 
-		// Add database connection to map of connections
-		resolver.conns[database] = conn
+		entry := &dbEntry{driver: dbDriver, dialector: dialect, resolver: resolver}
+		resolver.conns[dbDriver.Database] = entry
+
+		if !resolver.lazyConnect {
+			if _, err := entry.open(resolver.config); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if resolver.healthCheckInterval > 0 {
+		resolver.stopHealthCheck = make(chan struct{})
+		go resolver.runHealthCheckLoop()
+	}
+
+	if resolver.configWatchPath != "" {
+		resolver.stopConfigWatch = make(chan struct{})
+		if err := resolver.startConfigWatch(); err != nil {
+			return nil, err
+		}
 	}
 	return resolver, nil
 
 }
 
+// Close stops the background goroutines started via WithHealthCheckInterval
+// and WithConfigWatch. It is a no-op for whichever of those was never
+// enabled. It does not close the underlying database connections.
+func (resolver *DBResolver) Close() {
+	if resolver.stopHealthCheck != nil {
+		close(resolver.stopHealthCheck)
+	}
+	if resolver.stopConfigWatch != nil {
+		close(resolver.stopConfigWatch)
+	}
+}
+
 // createDB connects to a database with the provided driver and returns the connection.
 // If the database cannot be created, it panics.
 func createDB(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
@@ -112,11 +351,23 @@ func createDB(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// ErrUnhealthy is returned by resolveConnection when the health checker has
+// marked the resolved connection as unhealthy. Middleware maps it to a 503
+// instead of the generic 500 used for resolution failures.
+var ErrUnhealthy = errors.New("database connection is unhealthy")
+
+// ErrUnknownAPIKey is returned by resolveConnection when the API key has no
+// matching entry in the DatabaseConfig, as opposed to any other resolution
+// failure. Middleware counts these separately in its request metrics.
+var ErrUnknownAPIKey = errors.New("unknown API key")
+
 // ResolveDatabase resolves the database connection from the request APIKey.
 func (resolver *DBResolver) resolveConnection(apiKey string) (*gorm.DB, error) {
+	resolver.mu.RLock()
 	databaseMap, ok := resolver.databaseConfig[apiKey]
+	resolver.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("no database configuration found for API key: %q", apiKey)
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAPIKey, apiKey)
 	}
 
 	// Get the database databaseName
@@ -125,16 +376,27 @@ func (resolver *DBResolver) resolveConnection(apiKey string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("no database found for API key %q", apiKey)
 	}
 
-	conn, exists := resolver.conns[databaseName]
+	entry, exists := resolver.getEntry(databaseName)
 	if !exists {
 		return nil, fmt.Errorf("no valid connection exists for API key: %s", apiKey)
 	}
-	return conn, nil
+
+	db, err := entry.open(resolver.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, healthy := entry.get(); !healthy {
+		return nil, fmt.Errorf("%w: %s", ErrUnhealthy, databaseName)
+	}
+	return db, nil
 }
 
 // ResolveDatabase resolves the database name from the request APIKey.
 func (resolver *DBResolver) resolveDatabaseName(apiKey string) (string, error) {
+	resolver.mu.RLock()
 	databaseMap, ok := resolver.databaseConfig[apiKey]
+	resolver.mu.RUnlock()
 	if !ok {
 		return "", fmt.Errorf("no database configuration found for API key: %q", apiKey)
 	}
@@ -148,19 +410,36 @@ func (resolver *DBResolver) resolveDatabaseName(apiKey string) (string, error) {
 
 func (resolver *DBResolver) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get database name from request API key.
-		apiKey := r.Header.Get(apiKeyHeader)
-		if apiKey == "" {
-			apiKey = r.URL.Query().Get(apiKeyHeader)
+		// Get database name from request API key. Uses the registered
+		// KeyExtractors (see WithKeyExtractor), falling back to the
+		// x-api-key header/query param lookup when none are configured.
+		apiKey, err := resolver.extractAPIKey(r)
+		if err != nil {
+			log.Println(err)
+			resolver.metrics.requestTotal.WithLabelValues("no_key").Inc()
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
 		}
 
 		// Get underlying *gorm.DB for API key
 		db, err := resolver.resolveConnection(apiKey)
 		if err != nil {
 			log.Println(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			outcome := "error"
+			switch {
+			case errors.Is(err, ErrUnhealthy):
+				status = http.StatusServiceUnavailable
+				outcome = "unhealthy"
+			case errors.Is(err, ErrUnknownAPIKey):
+				status = http.StatusUnauthorized
+				outcome = "unknown_key"
+			}
+			resolver.metrics.requestTotal.WithLabelValues(outcome).Inc()
+			http.Error(w, err.Error(), status)
 			return
 		}
+		resolver.metrics.requestTotal.WithLabelValues("ok").Inc()
 
 		// Set the database connection in context
 		ctx := context.WithValue(r.Context(), ConnectionContextKey, db)
@@ -170,6 +449,19 @@ func (resolver *DBResolver) Middleware(next http.Handler) http.Handler {
 		dbname, _ := resolver.resolveDatabaseName(apiKey)
 		ctx = context.WithValue(ctx, DatabaseContextKey, dbname)
 
+		// Start a trace span for this request, annotated with the resolved
+		// tenant, if an OTel tracer was configured via WithTracer.
+		if resolver.tracer != nil {
+			var span trace.Span
+			entry, _ := resolver.getEntry(dbname)
+			attrs := []attribute.KeyValue{attribute.String("db.tenant", dbname)}
+			if entry != nil {
+				attrs = append(attrs, attribute.String("db.system", string(entry.driver.Driver)))
+			}
+			ctx, span = resolver.tracer.Start(ctx, "dbresolver.request", trace.WithAttributes(attrs...))
+			defer span.End()
+		}
+
 		// Serve the request
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -194,11 +486,20 @@ func (resolver *DBResolver) DBName(r *http.Request) string {
 // The error callback is only called if error is not nil.
 // The error callback allows you to ignore certain errors and return true.
 // If the error callback returns false this function will panic.
+// Databases opened via LazyConnect() are connected here if they haven't
+// been resolved yet, since migrating them requires an open connection.
+// For versioned, rollback-able migrations across tenants see Migrator.
 func (resolver *DBResolver) AutoMigrate(models []interface{}, errorCallback func(error) bool) {
-	for _, conn := range resolver.conns {
-		err := conn.AutoMigrate(models...)
+	for _, entry := range resolver.snapshotConns() {
+		conn, err := entry.open(resolver.config)
 		if err != nil && errorCallback(err) {
 			panic(err)
 		}
+		if conn == nil {
+			continue
+		}
+		if err := conn.AutoMigrate(models...); err != nil && errorCallback(err) {
+			panic(err)
+		}
 	}
 }
@@ -0,0 +1,48 @@
+package dbresolver
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// dialectRegistry holds GORM dialectors registered via RegisterDialect for
+// drivers not built into dbresolver (e.g. TiDB, CockroachDB, pgx).
+var dialectRegistry = struct {
+	mu sync.RWMutex
+	m  map[Driver]func(dsn string) gorm.Dialector
+}{m: make(map[Driver]func(dsn string) gorm.Dialector)}
+
+// RegisterDialect lets callers plug in an arbitrary GORM dialector under a
+// custom driver name, so DatabaseConfig entries can use it like any
+// built-in driver without patching dbresolver itself. For example:
+//
+//	dbresolver.RegisterDialect("cockroachdb", func(dsn string) gorm.Dialector {
+//		return postgres.Open(dsn)
+//	})
+func RegisterDialect(name string, opener func(dsn string) gorm.Dialector) {
+	dialectRegistry.mu.Lock()
+	defer dialectRegistry.mu.Unlock()
+	dialectRegistry.m[Driver(name)] = opener
+}
+
+// lookupDialect returns the opener registered for driver, if any.
+func lookupDialect(driver Driver) (func(dsn string) gorm.Dialector, bool) {
+	dialectRegistry.mu.RLock()
+	defer dialectRegistry.mu.RUnlock()
+	opener, ok := dialectRegistry.m[driver]
+	return opener, ok
+}
+
+// supportedDrivers lists every driver name dialectorFor can currently open,
+// built-in and registered, for use in error messages.
+func supportedDrivers() []string {
+	drivers := []string{string(Sqlite), string(MySQL), string(Postgres), string(MSSQL), string(ClickHouse)}
+
+	dialectRegistry.mu.RLock()
+	defer dialectRegistry.mu.RUnlock()
+	for name := range dialectRegistry.m {
+		drivers = append(drivers, string(name))
+	}
+	return drivers
+}
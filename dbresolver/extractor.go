@@ -0,0 +1,199 @@
+package dbresolver
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyExtractor pulls the tenant API key out of an incoming request. Several
+// implementations are provided (header, query param, cookie, HTTP Basic
+// auth, JWT claim); register one or more with WithKeyExtractor to replace
+// the default header/query lookup used by DBResolver.Middleware.
+type KeyExtractor interface {
+	Extract(r *http.Request) (string, error)
+}
+
+// WithKeyExtractor registers one or more KeyExtractors, tried in the order
+// given. The first extractor that returns a non-empty key wins; if all of
+// them fail, Middleware responds as if no API key was supplied. Calling this
+// more than once appends to the existing list rather than replacing it.
+func WithKeyExtractor(extractors ...KeyExtractor) Option {
+	return func(resolver *DBResolver) {
+		resolver.keyExtractors = append(resolver.keyExtractors, extractors...)
+	}
+}
+
+// extractAPIKey runs the configured KeyExtractors in order and returns the
+// first key found. When no extractors are registered, it falls back to the
+// original header/query-param lookup so existing callers keep working.
+func (resolver *DBResolver) extractAPIKey(r *http.Request) (string, error) {
+	if len(resolver.keyExtractors) == 0 {
+		apiKey := r.Header.Get(apiKeyHeader)
+		if apiKey == "" {
+			apiKey = r.URL.Query().Get(apiKeyHeader)
+		}
+		if apiKey == "" {
+			return "", fmt.Errorf("no API key found in header or query param %q", apiKeyHeader)
+		}
+		return apiKey, nil
+	}
+
+	var lastErr error
+	for _, extractor := range resolver.keyExtractors {
+		key, err := extractor.Extract(r)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if key != "" {
+			return key, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no API key found")
+	}
+	return "", lastErr
+}
+
+// HeaderExtractor reads the API key from a request header.
+type HeaderExtractor struct {
+	Name string
+}
+
+func (e HeaderExtractor) Extract(r *http.Request) (string, error) {
+	key := r.Header.Get(e.Name)
+	if key == "" {
+		return "", fmt.Errorf("header %q not present", e.Name)
+	}
+	return key, nil
+}
+
+// QueryExtractor reads the API key from a URL query parameter.
+type QueryExtractor struct {
+	Name string
+}
+
+func (e QueryExtractor) Extract(r *http.Request) (string, error) {
+	key := r.URL.Query().Get(e.Name)
+	if key == "" {
+		return "", fmt.Errorf("query param %q not present", e.Name)
+	}
+	return key, nil
+}
+
+// CookieExtractor reads the API key from a named cookie.
+type CookieExtractor struct {
+	Name string
+}
+
+func (e CookieExtractor) Extract(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(e.Name)
+	if err != nil {
+		return "", fmt.Errorf("cookie %q not present: %w", e.Name, err)
+	}
+	return cookie.Value, nil
+}
+
+// BasicAuthExtractor reads the API key from the username or password of an
+// HTTP Basic Authorization header.
+type BasicAuthExtractor struct {
+	// UseUsername selects the username as the API key. When false (the
+	// default), the password is used instead.
+	UseUsername bool
+}
+
+func (e BasicAuthExtractor) Extract(r *http.Request) (string, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", fmt.Errorf("no HTTP Basic auth credentials present")
+	}
+	if e.UseUsername {
+		return username, nil
+	}
+	return password, nil
+}
+
+// JWTExtractor verifies a bearer token from the Authorization header and
+// returns the value of a named claim (e.g. "tenant_id") as the API key.
+// Verification is done either against a shared secret or, when JWKSURL is
+// set, against keys fetched from that JWKS endpoint.
+type JWTExtractor struct {
+	// ClaimName is the JWT claim holding the API key, e.g. "tenant_id".
+	ClaimName string
+	// Secret verifies HMAC-signed tokens. Mutually exclusive with JWKSURL.
+	Secret []byte
+	// JWKSURL verifies RSA/ECDSA-signed tokens using keys fetched from an
+	// OIDC provider's JWKS endpoint. Mutually exclusive with Secret. Can be
+	// set directly on a struct literal; the backing client is built lazily
+	// on first use.
+	JWKSURL string
+
+	jwksMu sync.Mutex
+	jwks   *jwksClient
+}
+
+// NewJWTExtractor builds a JWTExtractor that reads claimName after verifying
+// the token's signature with the shared secret.
+func NewJWTExtractor(claimName string, secret []byte) *JWTExtractor {
+	return &JWTExtractor{ClaimName: claimName, Secret: secret}
+}
+
+// NewJWTExtractorWithJWKS builds a JWTExtractor that reads claimName after
+// verifying the token's signature against keys fetched from jwksURL.
+func NewJWTExtractorWithJWKS(claimName, jwksURL string) *JWTExtractor {
+	return &JWTExtractor{ClaimName: claimName, JWKSURL: jwksURL}
+}
+
+// jwksClientFor returns e's jwksClient, building it on first use so a
+// JWTExtractor assembled as a struct literal with JWKSURL set directly
+// (rather than through NewJWTExtractorWithJWKS) still works.
+func (e *JWTExtractor) jwksClientFor() *jwksClient {
+	e.jwksMu.Lock()
+	defer e.jwksMu.Unlock()
+	if e.jwks == nil {
+		e.jwks = newJWKSClient(e.JWKSURL)
+	}
+	return e.jwks
+}
+
+func (e *JWTExtractor) Extract(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(authHeader) <= len(prefix) || authHeader[:len(prefix)] != prefix {
+		return "", fmt.Errorf("no Bearer token present in Authorization header")
+	}
+	tokenString := authHeader[len(prefix):]
+
+	token, err := jwt.Parse(tokenString, e.keyFunc)
+	if err != nil || !token.Valid {
+		return "", fmt.Errorf("invalid JWT: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("unexpected JWT claims type")
+	}
+
+	value, ok := claims[e.ClaimName]
+	if !ok {
+		return "", fmt.Errorf("JWT missing claim %q", e.ClaimName)
+	}
+	key, ok := value.(string)
+	if !ok || key == "" {
+		return "", fmt.Errorf("JWT claim %q is not a non-empty string", e.ClaimName)
+	}
+	return key, nil
+}
+
+func (e *JWTExtractor) keyFunc(token *jwt.Token) (interface{}, error) {
+	if e.JWKSURL != "" {
+		return e.jwksClientFor().keyFor(token)
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return e.Secret, nil
+}
@@ -0,0 +1,106 @@
+package dbresolver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// WithHealthCheckInterval starts a background goroutine that re-pings every
+// configured connection every d, marking it unhealthy (and attempting a
+// reconnect) when a ping fails. Connections not yet opened under
+// LazyConnect() are left alone until they are first resolved.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(resolver *DBResolver) {
+		resolver.healthCheckInterval = d
+	}
+}
+
+// runHealthCheckLoop periodically calls HealthCheck until the resolver is
+// closed. It is started from New when WithHealthCheckInterval is set.
+func (resolver *DBResolver) runHealthCheckLoop() {
+	ticker := time.NewTicker(resolver.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			resolver.HealthCheck(context.Background())
+		case <-resolver.stopHealthCheck:
+			return
+		}
+	}
+}
+
+// HealthCheck pings every configured connection and returns the error (nil on
+// success) for each, keyed by database name. Connections that fail to ping
+// are marked unhealthy and a reconnect is attempted immediately using the
+// entry's stored dialector/DSN; resolveConnection and the middleware will
+// keep reporting 503 for a database until a reconnect succeeds.
+// Databases that are configured but have never been opened (LazyConnect, not
+// yet resolved) are skipped rather than forced open.
+func (resolver *DBResolver) HealthCheck(ctx context.Context) map[string]error {
+	conns := resolver.snapshotConns()
+	results := make(map[string]error, len(conns))
+
+	for name, entry := range conns {
+		db, _ := entry.get()
+		if db == nil {
+			continue
+		}
+
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+
+		if err != nil {
+			// Try to recover the connection in place so the next request
+			// doesn't have to pay for a failed dial. A successful reconnect
+			// clears err - the entry is healthy again even though the ping
+			// that triggered this failed.
+			if reconnectErr := entry.reconnect(resolver.config); reconnectErr != nil {
+				err = reconnectErr
+			} else {
+				err = nil
+			}
+		}
+
+		results[name] = err
+	}
+	return results
+}
+
+// healthStatus is the JSON shape returned by HealthHandler for one database.
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler returns an http.Handler that runs HealthCheck and writes a
+// JSON object of database name -> {status, error}, e.g.
+//
+//	{"tenant_a": {"status": "ok"}, "tenant_b": {"status": "error", "error": "..."}}
+func (resolver *DBResolver) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := resolver.HealthCheck(r.Context())
+
+		body := make(map[string]healthStatus, len(results))
+		allHealthy := true
+		for name, err := range results {
+			if err != nil {
+				allHealthy = false
+				body[name] = healthStatus{Status: "error", Error: err.Error()}
+			} else {
+				body[name] = healthStatus{Status: "ok"}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !allHealthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,187 @@
+package dbresolver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksClient fetches and caches an OIDC provider's JSON Web Key Set so
+// JWTExtractor can verify tokens without re-fetching keys on every request.
+// Both RSA ("RS*") and EC ("ES*") keys are supported, matching the key types
+// issuers commonly publish.
+type jwksClient struct {
+	url string
+
+	mu      sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// jwksCacheTTL bounds how long a fetched key set is reused before a JWKS
+// rotation (e.g. the issuer cycling signing keys) is picked up.
+const jwksCacheTTL = 10 * time.Minute
+
+func newJWKSClient(url string) *jwksClient {
+	return &jwksClient{url: url}
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	// RSA fields.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC fields.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keyFor returns the public key matching the token's "kid" header, pinning
+// the key's algorithm family (RSA vs ECDSA) against the token's signing
+// method so a key of one type can't be used to validate a token claiming
+// the other, refetching the key set if it's stale or the kid isn't cached.
+func (c *jwksClient) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("JWT missing kid header")
+	}
+
+	key := c.cachedKey(kid)
+	if key == nil {
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
+		key = c.cachedKey(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no JWKS key found for kid %q", kid)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("kid %q is an RSA key but token alg is %v", kid, token.Header["alg"])
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("kid %q is an EC key but token alg is %v", kid, token.Header["alg"])
+		}
+	}
+	return key, nil
+}
+
+func (c *jwksClient) cachedKey(kid string) crypto.PublicKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if time.Since(c.fetched) > jwksCacheTTL {
+		return nil
+	}
+	return c.keys[kid]
+}
+
+func (c *jwksClient) refresh() error {
+	resp, err := http.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS from %q: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS from %q: %w", c.url, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetched = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// publicKey decodes a JWK into its concrete Go key type, based on its "kty".
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		return k.rsaPublicKey()
+	case "EC":
+		return k.ecPublicKey()
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type %q", k.Kty)
+	}
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decoding JWK modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decoding JWK exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func (k jwk) ecPublicKey() (*ecdsa.PublicKey, error) {
+	curve, err := ecCurve(k.Crv)
+	if err != nil {
+		return nil, err
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decoding JWK x coordinate: %w", err)
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decoding JWK y coordinate: %w", err)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}, nil
+}
+
+func ecCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", name)
+	}
+}
@@ -0,0 +1,362 @@
+package dbresolver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a single versioned schema change, either loaded from a
+// numbered .sql file in a migrations directory, e.g. 0001_create_users.sql,
+// with the up and down statements marked goose-style:
+//
+//	-- +migrate Up
+//	CREATE TABLE users (...);
+//	-- +migrate Down
+//	DROP TABLE users;
+//
+// or registered in Go via RegisterGoMigration, in which case UpFunc/DownFunc
+// are set instead of Up/Down.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// UpFunc and DownFunc, when set, are run instead of executing Up/Down as
+	// SQL. Only set for migrations registered with RegisterGoMigration.
+	UpFunc   func(*gorm.DB) error
+	DownFunc func(*gorm.DB) error
+}
+
+// goMigrations holds every migration registered with RegisterGoMigration,
+// keyed by version.
+var goMigrations = struct {
+	mu sync.RWMutex
+	m  map[int64]Migration
+}{m: make(map[int64]Migration)}
+
+// RegisterGoMigration registers a migration implemented in Go instead of
+// .sql, for changes a raw SQL statement can't express (backfills,
+// conditional logic, calling out to other services). Go binaries can't
+// dynamically load and compile arbitrary .go files from a migrations
+// directory the way LoadMigrations reads .sql files, so Go migrations are
+// registered from code instead - typically a blank import of a package whose
+// init() calls RegisterGoMigration - and are merged with the .sql files
+// found in the directory passed to NewMigrator/LoadMigrations by version.
+// It panics if version is already registered, mirroring the conflict a
+// duplicate .sql filename would cause.
+func RegisterGoMigration(version int64, name string, up, down func(*gorm.DB) error) {
+	goMigrations.mu.Lock()
+	defer goMigrations.mu.Unlock()
+	if _, exists := goMigrations.m[version]; exists {
+		panic(fmt.Sprintf("dbresolver: Go migration %d already registered", version))
+	}
+	goMigrations.m[version] = Migration{Version: version, Name: name, UpFunc: up, DownFunc: down}
+}
+
+var migrationFileRE = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+const (
+	migrateUpMarker   = "-- +migrate Up"
+	migrateDownMarker = "-- +migrate Down"
+)
+
+// LoadMigrations reads every *.sql file in dir, merges in any migrations
+// registered with RegisterGoMigration, and returns them all sorted by
+// version. Files that don't match the `<version>_<name>.sql` naming
+// convention are skipped. It's an error for a .sql file and a registered Go
+// migration to share a version.
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations dir %q: %w", dir, err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int64]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRE.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %q: %w", entry.Name(), err)
+		}
+		up, down := splitUpDown(string(content))
+
+		migrations = append(migrations, Migration{Version: version, Name: match[2], Up: up, Down: down})
+		seen[version] = entry.Name()
+	}
+
+	goMigrations.mu.RLock()
+	defer goMigrations.mu.RUnlock()
+	for version, mig := range goMigrations.m {
+		if file, exists := seen[version]; exists {
+			return nil, fmt.Errorf("migration version %d registered in Go conflicts with %q", version, file)
+		}
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitUpDown pulls the Up/Down sections out of a migration file's content.
+// A file with no markers at all is treated as an up-only migration.
+func splitUpDown(content string) (up, down string) {
+	upIdx := strings.Index(content, migrateUpMarker)
+	downIdx := strings.Index(content, migrateDownMarker)
+
+	switch {
+	case upIdx == -1 && downIdx == -1:
+		return content, ""
+	case upIdx != -1 && downIdx != -1 && upIdx < downIdx:
+		return content[upIdx+len(migrateUpMarker) : downIdx], content[downIdx+len(migrateDownMarker):]
+	case upIdx != -1 && downIdx != -1:
+		return content[upIdx+len(migrateUpMarker):], content[downIdx+len(migrateDownMarker) : upIdx]
+	case upIdx != -1:
+		return content[upIdx+len(migrateUpMarker):], ""
+	default:
+		return "", content[downIdx+len(migrateDownMarker):]
+	}
+}
+
+// schemaMigration is one applied row of the per-database schema_migrations
+// table the Migrator uses to track progress.
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationResult is one database's outcome from a Migrator run.
+type MigrationResult struct {
+	// Applied lists the migration versions that ran during this call, in
+	// the order they ran (ascending for Up/To-forward, descending for
+	// Down/To-backward). For Status it instead lists every version recorded
+	// in schema_migrations, oldest first.
+	Applied []int64
+	// Err is set if a migration failed partway through; Applied still
+	// reflects everything that completed before the failure.
+	Err error
+}
+
+// Report maps database name to its MigrationResult. Every Migrator method
+// returns one instead of panicking, so operators can migrate a hundred
+// tenants and see exactly which ones failed.
+type Report map[string]MigrationResult
+
+// Migrator runs versioned .sql and Go migrations across every tenant
+// database known to a DBResolver, modeled on goose: numbered files (plus
+// anything registered with RegisterGoMigration), a schema_migrations table
+// per database, and Up/Down/To/Status operations that never abort the whole
+// batch over one tenant's failure.
+type Migrator struct {
+	resolver   *DBResolver
+	migrations []Migration
+}
+
+// NewMigrator builds a Migrator from every *.sql file in dir, merged with
+// any migrations registered via RegisterGoMigration.
+func NewMigrator(resolver *DBResolver, dir string) (*Migrator, error) {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{resolver: resolver, migrations: migrations}, nil
+}
+
+// Up applies every migration not yet recorded for a database, for every
+// database in the resolver.
+func (m *Migrator) Up(ctx context.Context) Report {
+	return m.forEachDB(func(db *gorm.DB) MigrationResult {
+		return m.applyUp(ctx, db, nil)
+	})
+}
+
+// Down rolls back the most recently applied migration on every database.
+func (m *Migrator) Down(ctx context.Context) Report {
+	return m.forEachDB(func(db *gorm.DB) MigrationResult {
+		applied, err := m.appliedVersions(db)
+		if err != nil {
+			return MigrationResult{Err: err}
+		}
+		if len(applied) == 0 {
+			return MigrationResult{}
+		}
+		if len(applied) == 1 {
+			return m.applyDown(ctx, db, nil)
+		}
+		previous := applied[len(applied)-2]
+		return m.applyDown(ctx, db, &previous)
+	})
+}
+
+// To migrates every database to exactly version, applying pending
+// migrations up through it or rolling back everything after it.
+func (m *Migrator) To(ctx context.Context, version int64) Report {
+	return m.forEachDB(func(db *gorm.DB) MigrationResult {
+		applied, err := m.appliedVersions(db)
+		if err != nil {
+			return MigrationResult{Err: err}
+		}
+		current := int64(0)
+		if len(applied) > 0 {
+			current = applied[len(applied)-1]
+		}
+		if version >= current {
+			return m.applyUp(ctx, db, &version)
+		}
+		return m.applyDown(ctx, db, &version)
+	})
+}
+
+// Status reports the applied versions for every database without changing
+// anything.
+func (m *Migrator) Status(ctx context.Context) Report {
+	return m.forEachDB(func(db *gorm.DB) MigrationResult {
+		applied, err := m.appliedVersions(db)
+		return MigrationResult{Applied: applied, Err: err}
+	})
+}
+
+// forEachDB opens (if needed) and runs fn against every database in the
+// resolver, collecting results into a Report keyed by database name.
+func (m *Migrator) forEachDB(fn func(db *gorm.DB) MigrationResult) Report {
+	conns := m.resolver.snapshotConns()
+	report := make(Report, len(conns))
+	for name, entry := range conns {
+		db, err := entry.open(m.resolver.config)
+		if err != nil {
+			report[name] = MigrationResult{Err: err}
+			continue
+		}
+		report[name] = fn(db)
+	}
+	return report
+}
+
+// appliedVersions returns every version recorded in db's schema_migrations
+// table, oldest first, creating the table if it doesn't exist yet.
+func (m *Migrator) appliedVersions(db *gorm.DB) ([]int64, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("ensuring schema_migrations table: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(rows))
+	for i, row := range rows {
+		versions[i] = row.Version
+	}
+	return versions, nil
+}
+
+// applyUp runs every pending migration in order, stopping after `ceiling`
+// if it is non-nil, stopping at the first failure otherwise.
+func (m *Migrator) applyUp(ctx context.Context, db *gorm.DB, ceiling *int64) MigrationResult {
+	applied, err := m.appliedVersions(db)
+	if err != nil {
+		return MigrationResult{Err: err}
+	}
+	alreadyApplied := make(map[int64]bool, len(applied))
+	for _, v := range applied {
+		alreadyApplied[v] = true
+	}
+
+	var ran []int64
+	for _, mig := range m.migrations {
+		if ceiling != nil && mig.Version > *ceiling {
+			break
+		}
+		if alreadyApplied[mig.Version] {
+			continue
+		}
+
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if mig.UpFunc != nil {
+				if err := mig.UpFunc(tx); err != nil {
+					return err
+				}
+			} else if strings.TrimSpace(mig.Up) != "" {
+				if err := tx.Exec(mig.Up).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return MigrationResult{Applied: ran, Err: fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)}
+		}
+		ran = append(ran, mig.Version)
+	}
+	return MigrationResult{Applied: ran}
+}
+
+// applyDown rolls back applied migrations newer than `floor` (nil means
+// roll back everything), newest first, stopping at the first failure.
+func (m *Migrator) applyDown(ctx context.Context, db *gorm.DB, floor *int64) MigrationResult {
+	applied, err := m.appliedVersions(db)
+	if err != nil {
+		return MigrationResult{Err: err}
+	}
+
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	var ran []int64
+	for i := len(applied) - 1; i >= 0; i-- {
+		version := applied[i]
+		if floor != nil && version <= *floor {
+			break
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			return MigrationResult{Applied: ran, Err: fmt.Errorf("no migration file found for applied version %d", version)}
+		}
+
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if mig.DownFunc != nil {
+				if err := mig.DownFunc(tx); err != nil {
+					return err
+				}
+			} else if strings.TrimSpace(mig.Down) != "" {
+				if err := tx.Exec(mig.Down).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&schemaMigration{}, "version = ?", version).Error
+		})
+		if err != nil {
+			return MigrationResult{Applied: ran, Err: fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)}
+		}
+		ran = append(ran, version)
+	}
+	return MigrationResult{Applied: ran}
+}
@@ -0,0 +1,41 @@
+package dbresolver
+
+import (
+	"gorm.io/gorm"
+	gormresolver "gorm.io/plugin/dbresolver"
+)
+
+// registerReadReplicas installs GORM's dbresolver plugin on db so reads are
+// load-balanced across driver.Replicas while writes and transactions keep
+// going to the primary connection (db itself). It is a no-op when no
+// replicas are configured.
+func registerReadReplicas(db *gorm.DB, driver DBDriver) error {
+	if len(driver.Replicas) == 0 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(driver.Replicas))
+	for _, dsn := range driver.Replicas {
+		dialect, err := dialectorFor(DBDriver{Driver: driver.Driver, Database: dsn})
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, dialect)
+	}
+
+	return db.Use(gormresolver.Register(gormresolver.Config{
+		Replicas: replicas,
+		Policy:   replicaPolicy(driver.Policy),
+	}))
+}
+
+// replicaPolicy maps the YAML "policy" string to a gormresolver.Policy,
+// defaulting to random load balancing like the plugin itself does.
+func replicaPolicy(name string) gormresolver.Policy {
+	switch name {
+	case "round-robin", "round_robin":
+		return gormresolver.RoundRobinPolicy()
+	default:
+		return gormresolver.RandomPolicy{}
+	}
+}
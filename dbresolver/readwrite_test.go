@@ -0,0 +1,103 @@
+package dbresolver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormresolver "gorm.io/plugin/dbresolver"
+)
+
+type probe struct {
+	ID     uint
+	Source string
+}
+
+// seedProbeDB creates a fresh sqlite file at path with a single probe row
+// tagged with source, so reads from it are identifiable.
+func seedProbeDB(t *testing.T, path, source string) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	if err := db.AutoMigrate(&probe{}); err != nil {
+		t.Fatalf("migrating %s: %v", path, err)
+	}
+	if err := db.Create(&probe{Source: source}).Error; err != nil {
+		t.Fatalf("seeding %s: %v", path, err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("getting sql.DB for %s: %v", path, err)
+	}
+	sqlDB.Close()
+}
+
+// TestRegisterReadReplicas_RoutesReadsAndWrites verifies that a plain query
+// is load-balanced to a replica while db.Clauses(dbresolver.Write) pins a
+// query to the primary connection, per the dbresolver.Write documentation.
+func TestRegisterReadReplicas_RoutesReadsAndWrites(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	seedProbeDB(t, primaryPath, "primary")
+	seedProbeDB(t, replicaPath, "replica")
+
+	db, err := gorm.Open(sqlite.Open(primaryPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("opening primary: %v", err)
+	}
+
+	driver := DBDriver{Driver: Sqlite, Database: primaryPath, Replicas: []string{replicaPath}}
+	if err := registerReadReplicas(db, driver); err != nil {
+		t.Fatalf("registerReadReplicas: %v", err)
+	}
+
+	var readResult probe
+	if err := db.First(&readResult).Error; err != nil {
+		t.Fatalf("read query: %v", err)
+	}
+	if readResult.Source != "replica" {
+		t.Errorf("plain read: got source %q, want %q (should route to replica)", readResult.Source, "replica")
+	}
+
+	var writeResult probe
+	if err := db.Clauses(gormresolver.Write).First(&writeResult).Error; err != nil {
+		t.Fatalf("write-clause query: %v", err)
+	}
+	if writeResult.Source != "primary" {
+		t.Errorf("dbresolver.Write read: got source %q, want %q (should route to primary)", writeResult.Source, "primary")
+	}
+}
+
+// TestRegisterReadReplicas_NoReplicasIsNoop verifies that a DBDriver with no
+// Replicas configured leaves reads and writes both on the primary
+// connection, without installing the dbresolver plugin at all.
+func TestRegisterReadReplicas_NoReplicasIsNoop(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	seedProbeDB(t, primaryPath, "primary")
+
+	db, err := gorm.Open(sqlite.Open(primaryPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("opening primary: %v", err)
+	}
+
+	driver := DBDriver{Driver: Sqlite, Database: primaryPath}
+	if err := registerReadReplicas(db, driver); err != nil {
+		t.Fatalf("registerReadReplicas: %v", err)
+	}
+
+	var result probe
+	if err := db.First(&result).Error; err != nil {
+		t.Fatalf("read query: %v", err)
+	}
+	if result.Source != "primary" {
+		t.Errorf("got source %q, want %q", result.Source, "primary")
+	}
+}
@@ -0,0 +1,170 @@
+package dbresolver
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// AddTenant registers a new API key -> database mapping and opens its
+// connection (unless LazyConnect() is in effect, in which case it opens on
+// first resolution like any other tenant). It is safe to call concurrently
+// with Middleware and with itself.
+func (resolver *DBResolver) AddTenant(apiKey string, d DBDriver) error {
+	dialect, err := dialectorFor(d)
+	if err != nil {
+		return err
+	}
+
+	entry := &dbEntry{driver: d, dialector: dialect, resolver: resolver}
+	if !resolver.lazyConnect {
+		if _, err := entry.open(resolver.config); err != nil {
+			return err
+		}
+	}
+
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+	resolver.conns[d.Database] = entry
+	resolver.databaseConfig[apiKey] = map[string]string{
+		"driver":   string(d.Driver),
+		"database": d.Database,
+	}
+	return nil
+}
+
+// RemoveTenant removes an API key's mapping. The underlying connection is
+// only closed if no other API key still points at the same database.
+func (resolver *DBResolver) RemoveTenant(apiKey string) error {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	dbmap, ok := resolver.databaseConfig[apiKey]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownAPIKey, apiKey)
+	}
+	databaseName := dbmap["database"]
+	delete(resolver.databaseConfig, apiKey)
+
+	for _, other := range resolver.databaseConfig {
+		if other["database"] == databaseName {
+			// Another tenant still uses this database; keep the connection.
+			return nil
+		}
+	}
+
+	if entry, exists := resolver.conns[databaseName]; exists {
+		closeEntry(entry)
+		delete(resolver.conns, databaseName)
+	}
+	return nil
+}
+
+// ReloadFromYAML reloads the resolver's DatabaseConfig from path, opening
+// connections for newly added databases, closing connections for removed
+// ones, and leaving unchanged databases' connections untouched.
+func (resolver *DBResolver) ReloadFromYAML(path string) error {
+	newConfig, err := ConfigFromYAMLFile(path)
+	if err != nil {
+		return err
+	}
+	return resolver.reload(newConfig)
+}
+
+// reload diffs newConfig's databases against the current connection set,
+// opening new ones before taking the write lock and closing removed ones
+// while holding it.
+func (resolver *DBResolver) reload(newConfig DatabaseConfig) error {
+	newDrivers := newConfig.DatabaseDrivers()
+
+	newConns := make(map[string]*dbEntry, len(newDrivers))
+	for _, d := range newDrivers {
+		if existing, ok := resolver.getEntry(d.Database); ok {
+			newConns[d.Database] = existing
+			continue
+		}
+
+		dialect, err := dialectorFor(d)
+		if err != nil {
+			return err
+		}
+		entry := &dbEntry{driver: d, dialector: dialect, resolver: resolver}
+		if !resolver.lazyConnect {
+			if _, err := entry.open(resolver.config); err != nil {
+				return err
+			}
+		}
+		newConns[d.Database] = entry
+	}
+
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+	for name, entry := range resolver.conns {
+		if _, kept := newConns[name]; !kept {
+			closeEntry(entry)
+		}
+	}
+	resolver.conns = newConns
+	resolver.databaseConfig = newConfig
+	return nil
+}
+
+// closeEntry closes the *sql.DB backing entry, if it was ever opened.
+func closeEntry(entry *dbEntry) {
+	db, _ := entry.get()
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("dbresolver: closing connection for %s: %v", entry.driver.Database, err)
+		}
+	}
+}
+
+// WithConfigWatch watches the YAML config at path with fsnotify and calls
+// ReloadFromYAML whenever it changes, so multi-tenant SaaS operators can add
+// or remove customers without restarting the process.
+func WithConfigWatch(path string) Option {
+	return func(resolver *DBResolver) {
+		resolver.configWatchPath = path
+	}
+}
+
+// startConfigWatch launches the fsnotify watch loop for configWatchPath.
+func (resolver *DBResolver) startConfigWatch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("starting config watcher: %w", err)
+	}
+	if err := watcher.Add(resolver.configWatchPath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watching %q: %w", resolver.configWatchPath, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+					if err := resolver.ReloadFromYAML(resolver.configWatchPath); err != nil {
+						log.Printf("dbresolver: reloading config from %q: %v", resolver.configWatchPath, err)
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("dbresolver: config watcher error: %v", err)
+			case <-resolver.stopConfigWatch:
+				return
+			}
+		}
+	}()
+	return nil
+}